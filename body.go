@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// bodySample is what we learn from reading a capped prefix of a response
+// body: its content, a title (if it looks like HTML), and a hash over the
+// sampled bytes that's stable across parked/holding pages that are
+// byte-for-byte identical.
+type bodySample struct {
+	Sample []byte
+	Length int64
+	Title  string
+	Hash   string
+}
+
+// readBodySample reads up to maxBytes of resp.Body - the same read the
+// code always did via io.Copy(ioutil.Discard, ...), just capped and
+// hashed instead of thrown away.
+func readBodySample(resp *http.Response, maxBytes int) bodySample {
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)))
+
+	length := resp.ContentLength
+	if length < 0 {
+		length = int64(len(data))
+	}
+
+	sum := sha256.Sum256(data)
+
+	return bodySample{
+		Sample: data,
+		Length: length,
+		Title:  extractTitle(data),
+		Hash:   hex.EncodeToString(sum[:]),
+	}
+}
+
+// extractTitle pulls the text of an HTML <title> element out of a body
+// sample, collapsing internal whitespace. Returns "" if none is found
+// within the sampled bytes.
+func extractTitle(body []byte) string {
+	m := titleRe.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+
+	return strings.Join(strings.Fields(string(m[1])), " ")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// applyCertInfo copies the leaf certificate's subject/SAN details, plus the
+// earliest expiry across the whole presented chain, from a completed TLS
+// handshake onto a Result. The earliest-of-the-chain expiry mirrors
+// blackbox_exporter's getEarliestCertExpiry: an intermediate expiring before
+// the leaf is just as real a "cert about to break" signal. minCertDays of 0
+// disables the expiry flag.
+func applyCertInfo(result *Result, cs *tls.ConnectionState, minCertDays int) {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return
+	}
+
+	leaf := cs.PeerCertificates[0]
+
+	result.CertCommonName = leaf.Subject.CommonName
+	result.CertSANs = leaf.DNSNames
+	result.CertIssuer = leaf.Issuer.CommonName
+	result.CertNotBefore = leaf.NotBefore.UTC().Format(time.RFC3339)
+
+	earliest := leaf.NotAfter
+	for _, cert := range cs.PeerCertificates[1:] {
+		if cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+
+	result.CertNotAfter = earliest.UTC().Format(time.RFC3339)
+	result.CertDaysRemaining = daysUntil(earliest)
+
+	if minCertDays > 0 && result.CertDaysRemaining <= minCertDays {
+		result.CertExpiringSoon = true
+	}
+}
+
+// daysUntil returns the number of whole days between now and t, which may
+// be negative for a certificate that has already expired.
+func daysUntil(t time.Time) int {
+	return int(time.Until(t).Hours() / 24)
+}
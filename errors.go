@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+)
+
+// classifyError buckets a failed probe's error into one of a handful of
+// classes, so the JSON output can tell "host down" apart from "TLS
+// broken" apart from "blocked/rate-limited" when triaging results.
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "dns_failure"
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		if opErr.Timeout() {
+			return "tcp_connect_timeout"
+		}
+		return "tcp_error"
+	}
+
+	var unknownAuthErr x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthErr) {
+		return "tls_handshake_error"
+	}
+
+	var headerErr tls.RecordHeaderError
+	if errors.As(err, &headerErr) {
+		return "tls_handshake_error"
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "deadline_exceeded"
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "deadline_exceeded"
+	}
+
+	// Anything else that made it out of client.Do is a protocol-level
+	// problem: malformed responses, too many redirects, bad status lines.
+	return "http_protocol_error"
+}
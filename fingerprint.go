@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// fingerprintRule matches a single technology against response headers
+// and/or a substring search over the sampled response body. A rule
+// matches if any of its conditions match.
+type fingerprintRule struct {
+	Name         string            `json:"name"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	BodyContains []string          `json:"body_contains,omitempty"`
+}
+
+// loadFingerprintRules reads a JSON array of fingerprintRule from path.
+func loadFingerprintRules(path string) ([]fingerprintRule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fingerprints file: %w", err)
+	}
+
+	var rules []fingerprintRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing fingerprints file: %w", err)
+	}
+
+	return rules, nil
+}
+
+// matchFingerprints returns the names of every rule that matches header
+// or body.
+func matchFingerprints(header http.Header, body []byte, rules []fingerprintRule) []string {
+	lowerBody := strings.ToLower(string(body))
+
+	var matched []string
+	for _, rule := range rules {
+		if fingerprintMatches(rule, header, lowerBody) {
+			matched = append(matched, rule.Name)
+		}
+	}
+
+	return matched
+}
+
+func fingerprintMatches(rule fingerprintRule, header http.Header, lowerBody string) bool {
+	for name, want := range rule.Headers {
+		if got := header.Get(name); got != "" && strings.Contains(strings.ToLower(got), strings.ToLower(want)) {
+			return true
+		}
+	}
+
+	for _, want := range rule.BodyContains {
+		if want != "" && strings.Contains(lowerBody, strings.ToLower(want)) {
+			return true
+		}
+	}
+
+	return false
+}
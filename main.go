@@ -3,7 +3,6 @@ package main
 import (
 	"bufio"
 	"crypto/tls"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -11,10 +10,11 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/net/http2"
 )
 
 type probeArgs []string
@@ -33,6 +33,37 @@ type Result struct {
 	URL           string `json:"url"`
 	StatusCode    int    `json:"status_code"`
 	RedirectedURL string `json:"redirected_url,omitempty"`
+	Protocol      string `json:"protocol,omitempty"`
+
+	CertCommonName    string   `json:"cert_cn,omitempty"`
+	CertSANs          []string `json:"cert_sans,omitempty"`
+	CertIssuer        string   `json:"cert_issuer,omitempty"`
+	CertNotBefore     string   `json:"cert_not_before,omitempty"`
+	CertNotAfter      string   `json:"cert_not_after,omitempty"`
+	CertDaysRemaining int      `json:"cert_days_remaining,omitempty"`
+	CertExpiringSoon  bool     `json:"cert_expiring_soon,omitempty"`
+
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+
+	Title         string   `json:"title,omitempty"`
+	BodyHash      string   `json:"body_sha256,omitempty"`
+	ContentLength int64    `json:"content_length,omitempty"`
+	Tech          []string `json:"tech,omitempty"`
+
+	ErrorClass   string `json:"error_class,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// probeOptions bundles the per-request knobs that used to be passed to
+// getStatusAndRedirect positionally; it grew too large for that once body
+// sampling and fingerprinting joined the method and min-cert-days knobs.
+type probeOptions struct {
+	Method       string
+	BodyBytes    int
+	MinCertDays  int
+	Fingerprints []fingerprintRule
+	Retries      int
+	RetryBackoff time.Duration
 }
 
 func main() {
@@ -61,6 +92,58 @@ func main() {
 	var method string
 	flag.StringVar(&method, "method", "GET", "HTTP method to use")
 
+	// HTTP/2 flags
+	var useHTTP2 bool
+	flag.BoolVar(&useHTTP2, "http2", false, "negotiate HTTP/2 via ALPN when probing HTTPS URLs")
+
+	var h2Only bool
+	flag.BoolVar(&h2Only, "h2-only", false, "only report HTTPS results that negotiated HTTP/2 (failed probes are still reported)")
+
+	// certificate pivoting/expiry flags
+	var captureSANs bool
+	flag.BoolVar(&captureSANs, "sans", false, "feed discovered certificate SANs back into the queue for a second probing round")
+
+	var minCertDays int
+	flag.IntVar(&minCertDays, "min-cert-days", 0, "flag certificates expiring within N days in the JSON output (0 = disabled)")
+
+	// mTLS flags
+	var certFile string
+	flag.StringVar(&certFile, "cert", "", "client certificate file, for endpoints that require mTLS")
+
+	var keyFile string
+	flag.StringVar(&keyFile, "key", "", "client private key file (may be encrypted; you'll be prompted for a passphrase)")
+
+	var caFile string
+	flag.StringVar(&caFile, "ca", "", "CA bundle to verify server certificates against, instead of the insecure default")
+
+	// output flags
+	var outputFormat string
+	flag.StringVar(&outputFormat, "o", "json", "output format: json|ndjson|csv|prom")
+
+	var outPath string
+	flag.StringVar(&outPath, "out", "", "output file path (default: a timestamped file for json, stdout for streaming formats)")
+
+	// body-content signal flags
+	var bodyBytes int
+	flag.IntVar(&bodyBytes, "body-bytes", 0, "read up to N bytes of the response body to compute title/hash/fingerprints (0 = off)")
+
+	var fingerprintsFile string
+	flag.StringVar(&fingerprintsFile, "fingerprints", "", "JSON rules file for tech fingerprinting (requires -body-bytes)")
+
+	// retry flags
+	var retries int
+	flag.IntVar(&retries, "retries", 0, "number of retries on failure (0 = no retries)")
+
+	var retryBackoffMs int
+	flag.IntVar(&retryBackoffMs, "retry-backoff", 500, "base backoff in milliseconds for retries (exponential, with jitter)")
+
+	// proxy/rate-limit flags
+	var proxyURLFlag string
+	flag.StringVar(&proxyURLFlag, "proxy", "", "proxy to route probes through (http://, https://, or socks5://)")
+
+	var qps int
+	flag.IntVar(&qps, "qps", 0, "global rate limit across all probes, in requests/sec (0 = unlimited)")
+
 	flag.Parse()
 
 	// make an actual time.Duration out of the timeout
@@ -77,6 +160,47 @@ func main() {
 		}).DialContext,
 	}
 
+	if err := configureProxy(tr, proxyURLFlag, timeout, time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure proxy: %s\n", err)
+		os.Exit(1)
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			fmt.Fprintln(os.Stderr, "-cert and -key must both be supplied for mTLS")
+			os.Exit(1)
+		}
+
+		clientCert, err := loadClientCertificate(certFile, keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load client certificate: %s\n", err)
+			os.Exit(1)
+		}
+
+		tr.TLSClientConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	if caFile != "" {
+		pool, err := loadCAPool(caFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load CA bundle: %s\n", err)
+			os.Exit(1)
+		}
+
+		// A custom trust root lets us verify properly instead of skipping
+		// verification altogether.
+		tr.TLSClientConfig.RootCAs = pool
+		tr.TLSClientConfig.InsecureSkipVerify = false
+	}
+
+	// ConfigureTransport wires up ALPN (h2, http/1.1) on tr.TLSClientConfig.NextProtos
+	// and teaches tr to speak HTTP/2 over TLS when the server negotiates it.
+	if useHTTP2 {
+		if err := http2.ConfigureTransport(tr); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to configure HTTP/2 transport: %s\n", err)
+		}
+	}
+
 	re := func(req *http.Request, via []*http.Request) error {
 		return http.ErrUseLastResponse
 	}
@@ -87,6 +211,113 @@ func main() {
 		Timeout:       timeout,
 	}
 
+	writer, err := newOutputWriter(outputFormat, outPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up output writer: %s\n", err)
+		os.Exit(1)
+	}
+
+	var fingerprints []fingerprintRule
+	if fingerprintsFile != "" {
+		fingerprints, err = loadFingerprintRules(fingerprintsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load fingerprint rules: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	opts := probeOptions{
+		Method:       method,
+		BodyBytes:    bodyBytes,
+		MinCertDays:  minCertDays,
+		Fingerprints: fingerprints,
+		Retries:      retries,
+		RetryBackoff: time.Duration(retryBackoffMs) * time.Millisecond,
+	}
+
+	// a shared token-bucket channel throttles both worker pools to -qps
+	// requests/sec in total; nil disables rate limiting entirely.
+	var limiter <-chan time.Time
+	if qps > 0 {
+		limiter = time.Tick(time.Second / time.Duration(qps))
+	}
+
+	// seen tracks every domain that has already been queued, so a -sans
+	// pivot round doesn't re-probe a host we already have a result for.
+	seen := make(map[string]bool)
+
+	domainCh := make(chan string)
+	go func() {
+		defer close(domainCh)
+
+		sc := bufio.NewScanner(os.Stdin)
+		for sc.Scan() {
+			domain := strings.ToLower(sc.Text())
+			fmt.Fprintf(os.Stderr, "Processing domain: %s\n", domain)
+			seen[domain] = true
+			domainCh <- domain
+		}
+
+		// check there were no errors reading stdin (unlikely)
+		if err := sc.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read input: %s\n", err)
+		}
+	}()
+
+	allResults := runProbePass(domainCh, concurrency, probes, skipDefault, preferHTTPS, client, h2Only, writer, opts, limiter)
+
+	if captureSANs {
+		pivot := pivotDomains(allResults, seen)
+		if len(pivot) > 0 {
+			fmt.Fprintf(os.Stderr, "Pivoting on %d discovered SAN(s) for a second probing round...\n", len(pivot))
+
+			pivotCh := make(chan string)
+			go func() {
+				defer close(pivotCh)
+				for _, d := range pivot {
+					pivotCh <- d
+				}
+			}()
+
+			sanResults := runProbePass(pivotCh, concurrency, probes, skipDefault, preferHTTPS, client, h2Only, writer, opts, limiter)
+			allResults = append(allResults, sanResults...)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to finalize output: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// pivotDomains collects the deduped set of certificate SANs discovered
+// across results that haven't already been queued, so they can be fed
+// back in for a second probing round.
+func pivotDomains(results []Result, seen map[string]bool) []string {
+	var pivot []string
+	pivotSeen := make(map[string]bool)
+
+	for _, r := range results {
+		for _, san := range r.CertSANs {
+			san = strings.ToLower(strings.TrimPrefix(san, "*."))
+			if san == "" || seen[san] || pivotSeen[san] {
+				continue
+			}
+			pivotSeen[san] = true
+			pivot = append(pivot, san)
+		}
+	}
+
+	return pivot
+}
+
+// runProbePass fans domains out across the HTTPS/HTTP worker pools,
+// streaming each result to writer as it arrives, and blocks until every
+// result has been collected. It also returns the collected results so
+// callers that need to look back across the whole pass (e.g. -sans
+// pivoting) can do so.
+func runProbePass(domains <-chan string, concurrency int, probes probeArgs, skipDefault, preferHTTPS bool, client *http.Client, h2Only bool, writer OutputWriter, opts probeOptions, limiter <-chan time.Time) []Result {
+
 	// domain/port pairs are initially sent on the httpsURLs channel.
 	// If they are listening and the --prefer-https flag is set then
 	// no HTTP check is performed; otherwise they're put onto the httpURLs
@@ -102,23 +333,34 @@ func main() {
 
 		go func() {
 			for url := range httpsURLs {
-				fmt.Printf("Probing HTTPS URL: %s\n", url)
+				fmt.Fprintf(os.Stderr, "Probing HTTPS URL: %s\n", url)
 
 				// always try HTTPS first
 				withProto := "https://" + url
-				statusCode, redirectedURL := getStatusAndRedirect(client, withProto, method)
-				if statusCode != 0 {
-					fmt.Printf("Found HTTPS URL: %s with status code: %d\n", withProto, statusCode)
-					output <- Result{
-						URL:           withProto,
-						StatusCode:    statusCode,
-						RedirectedURL: redirectedURL,
+				if limiter != nil {
+					<-limiter
+				}
+				result := probe(client, withProto, opts)
+				if result.StatusCode != 0 {
+					fmt.Fprintf(os.Stderr, "Found HTTPS URL: %s with status code: %d (%s)\n", withProto, result.StatusCode, result.Protocol)
+
+					// -h2-only drops HTTPS results that didn't negotiate HTTP/2,
+					// but the host still falls through to the HTTP check below.
+					if !h2Only || result.Protocol == "h2" {
+						output <- result
 					}
 
 					// skip trying HTTP if --prefer-https is set
 					if preferHTTPS {
 						continue
 					}
+				} else {
+					// The HTTPS probe itself failed (TLS handshake error, bad
+					// cert, connection refused, ...) - surface that failure now,
+					// instead of letting a same-host HTTP attempt's unrelated
+					// result (success or a different error) paper over it.
+					fmt.Fprintf(os.Stderr, "Failed HTTPS URL: %s (%s)\n", withProto, result.ErrorClass)
+					output <- result
 				}
 
 				httpURLs <- url
@@ -135,19 +377,28 @@ func main() {
 
 		go func() {
 			for url := range httpURLs {
-				fmt.Printf("Probing HTTP URL: %s\n", url)
+				fmt.Fprintf(os.Stderr, "Probing HTTP URL: %s\n", url)
 
 				withProto := "http://" + url
-				statusCode, redirectedURL := getStatusAndRedirect(client, withProto, method)
-				if statusCode != 0 {
-					fmt.Printf("Found HTTP URL: %s with status code: %d\n", withProto, statusCode)
-					output <- Result{
-						URL:           withProto,
-						StatusCode:    statusCode,
-						RedirectedURL: redirectedURL,
+				if limiter != nil {
+					<-limiter
+				}
+				result := probe(client, withProto, opts)
+				if result.StatusCode != 0 {
+					fmt.Fprintf(os.Stderr, "Found HTTP URL: %s with status code: %d\n", withProto, result.StatusCode)
+
+					// plain HTTP never negotiates ALPN, so -h2-only drops every
+					// successful HTTP result; failures are still reported below
+					if !h2Only {
+						output <- result
 					}
 					continue
 				}
+
+				// the HTTP probe for this URL failed; surface its classified
+				// error instead of dropping it.
+				fmt.Fprintf(os.Stderr, "Failed HTTP URL: %s (%s)\n", withProto, result.ErrorClass)
+				output <- result
 			}
 
 			httpWG.Done()
@@ -160,15 +411,17 @@ func main() {
 		close(httpURLs)
 	}()
 
-	// Collect results into a map grouped by status code
-	results := make(map[int][]Result)
+	var results []Result
 
 	// Output worker
 	var outputWG sync.WaitGroup
 	outputWG.Add(1)
 	go func() {
 		for res := range output {
-			results[res.StatusCode] = append(results[res.StatusCode], res)
+			results = append(results, res)
+			if err := writer.Write(res); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to write result: %s\n", err)
+			}
 		}
 		outputWG.Done()
 	}()
@@ -179,12 +432,7 @@ func main() {
 		close(output)
 	}()
 
-	// accept domains on stdin
-	sc := bufio.NewScanner(os.Stdin)
-	for sc.Scan() {
-		domain := strings.ToLower(sc.Text())
-		fmt.Printf("Processing domain: %s\n", domain)
-
+	for domain := range domains {
 		// submit standard port checks
 		if (!skipDefault) && (len(probes) == 0) {
 			httpsURLs <- domain
@@ -227,104 +475,85 @@ func main() {
 	}
 
 	// once we've sent all the URLs off we can close the
-	// input/httpsURLs channel. The workers will finish what they're
+	// httpsURLs channel. The workers will finish what they're
 	// doing and then call 'Done' on the WaitGroup
 	close(httpsURLs)
 
-	// check there were no errors reading stdin (unlikely)
-	if err := sc.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to read input: %s\n", err)
-	}
-
 	// Wait until the output waitgroup is done
 	outputWG.Wait()
 
-	// Generate JSON file name
-	jsonFileName := generateJSONFileName()
-	jsonOutputFilePath, _ := filepath.Abs(jsonFileName)
-
-	// Display the message in the terminal that we are creating the JSON file
-	fmt.Println("Creating JSON file...")
-
-	// Create and write to the JSON file
-	writeJSONFile(jsonOutputFilePath, results)
+	return results
 }
 
-// Generate a unique JSON file name based on the current date and time
-func generateJSONFileName() string {
-	timestamp := time.Now().Format("20060102_150405")
-	return fmt.Sprintf("%s_scan.json", timestamp)
-}
+// probe performs a single HTTP request against url and returns a Result
+// with everything we could learn from it filled in: status/redirect,
+// negotiated ALPN protocol, TLS certificate metadata, body-content
+// signals, and timing. A zero StatusCode means the request failed.
+func probe(client *http.Client, url string, opts probeOptions) Result {
+	result := Result{URL: url}
 
-func writeJSONFile(jsonOutputFilePath string, results map[int][]Result) {
-	jsonData := make(map[string]interface{})
-
-	for statusCode, resList := range results {
-		codeStr := fmt.Sprintf("%d", statusCode)
-		var entries []interface{}
-
-		for _, res := range resList {
-			if statusCode >= 300 && statusCode < 400 {
-				// For 3xx status codes, include redirection information with URL first
-				entry := struct {
-					URL           string `json:"url"`
-					RedirectedURL string `json:"redirected_url"`
-				}{
-					URL:           res.URL,
-					RedirectedURL: res.RedirectedURL,
-				}
-				entries = append(entries, entry)
-			} else {
-				// For other status codes, just include the URL
-				entries = append(entries, res.URL)
-			}
-		}
-
-		jsonData[codeStr] = entries
-	}
+	start := time.Now()
 
-	jsonFile, err := os.Create(jsonOutputFilePath)
+	req, err := http.NewRequest(opts.Method, url, nil)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to create JSON file: %s\n", err)
-		return
+		result.ErrorClass = "request_error"
+		result.ErrorMessage = err.Error()
+		return result
 	}
-	defer jsonFile.Close()
 
-	encoder := json.NewEncoder(jsonFile)
-	encoder.SetIndent("", "    ")
-	if err := encoder.Encode(jsonData); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to write to JSON file: %s\n", err)
-		return
-	}
-
-	fmt.Printf("JSON file created successfully at: %s\n", jsonOutputFilePath)
-}
+	req.Header.Add("Connection", "close")
+	req.Close = true
 
+	var resp *http.Response
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		resp, err = client.Do(req)
+		if err == nil || attempt == opts.Retries {
+			break
+		}
 
-func getStatusAndRedirect(client *http.Client, url, method string) (int, string) {
-	req, err := http.NewRequest(method, url, nil)
-	if err != nil {
-		return 0, ""
+		// this attempt failed and we have another one left: close its
+		// body now, since the retry loop is about to discard resp.
+		if resp != nil {
+			resp.Body.Close()
+		}
+		sleepBackoff(opts.RetryBackoff, attempt)
 	}
 
-	req.Header.Add("Connection", "close")
-	req.Close = true
-
-	resp, err := client.Do(req)
 	if resp != nil {
 		defer resp.Body.Close()
-		io.Copy(ioutil.Discard, resp.Body)
+
+		if opts.BodyBytes > 0 {
+			body := readBodySample(resp, opts.BodyBytes)
+			result.Title = body.Title
+			result.BodyHash = body.Hash
+			result.ContentLength = body.Length
+			if len(opts.Fingerprints) > 0 {
+				result.Tech = matchFingerprints(resp.Header, body.Sample, opts.Fingerprints)
+			}
+		} else {
+			io.Copy(ioutil.Discard, resp.Body)
+		}
 	}
 
+	result.DurationSeconds = time.Since(start).Seconds()
+
 	if err != nil {
-		return 0, ""
+		result.ErrorClass = classifyError(err)
+		result.ErrorMessage = err.Error()
+		return result
 	}
 
-	redirectedURL := ""
+	result.StatusCode = resp.StatusCode
 	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
-		redirectedURL = resp.Header.Get("Location")
+		result.RedirectedURL = resp.Header.Get("Location")
 	}
 
-	return resp.StatusCode, redirectedURL
-}
+	// resp.TLS is only populated for HTTPS requests; NegotiatedProtocol
+	// reports what ALPN actually agreed on (e.g. "h2", "http/1.1").
+	if resp.TLS != nil {
+		result.Protocol = resp.TLS.NegotiatedProtocol
+		applyCertInfo(&result, resp.TLS, opts.MinCertDays)
+	}
 
+	return result
+}
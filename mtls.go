@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// loadClientCertificate reads a cert/key pair for mTLS, transparently
+// decrypting the private key (prompting for a passphrase) if it's
+// encrypted.
+func loadClientCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("reading cert file: %w", err)
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("reading key file: %w", err)
+	}
+
+	keyPEM, err = decryptKeyIfNeeded(keyFile, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("parsing client keypair: %w", err)
+	}
+
+	return cert, nil
+}
+
+// decryptKeyIfNeeded detects PKCS#8 (`ENCRYPTED PRIVATE KEY`) or legacy
+// (`Proc-Type: 4,ENCRYPTED`) encrypted private keys and, if found, prompts
+// for a passphrase on the controlling terminal and returns a decrypted,
+// PEM-encoded key. Unencrypted keys are returned unchanged.
+func decryptKeyIfNeeded(keyFile string, keyPEM []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM block found in key file")
+	}
+
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		passphrase, err := promptPassphrase(fmt.Sprintf("Enter passphrase for %s: ", keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase: %w", err)
+		}
+		return decryptPKCS8(block.Bytes, passphrase)
+
+	case x509.IsEncryptedPEMBlock(block):
+		passphrase, err := promptPassphrase(fmt.Sprintf("Enter passphrase for %s: ", keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase: %w", err)
+		}
+
+		der, err := x509.DecryptPEMBlock(block, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting private key: %w", err)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+
+	default:
+		return keyPEM, nil
+	}
+}
+
+// promptPassphrase reads a passphrase from the controlling terminal with
+// echo disabled, falling back to stdin if /dev/tty can't be opened (e.g.
+// there is no controlling terminal).
+func promptPassphrase(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	fd := int(os.Stdin.Fd())
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		fd = int(tty.Fd())
+	}
+
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	return passphrase, nil
+}
+
+// loadCAPool reads a PEM bundle to use instead of the system root pool,
+// so internal/private CAs can be verified without InsecureSkipVerify.
+func loadCAPool(caFile string) (*x509.CertPool, error) {
+	pemBytes, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("no certificates found in %s", strings.TrimSpace(caFile))
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutputWriter receives results as they're produced by a probing pass and
+// is responsible for getting them onto disk/stdout in some format. The
+// streaming formats (ndjson, csv, prom) write each Result immediately and
+// never buffer the full result set; the grouped JSON format still buffers
+// internally, since it needs the whole set to group by status code.
+type OutputWriter interface {
+	Write(Result) error
+	Close() error
+}
+
+// newOutputWriter builds the OutputWriter for the given -o format, opening
+// path (or stdout, for streaming formats, if path is empty).
+func newOutputWriter(format, path string) (OutputWriter, error) {
+	switch strings.ToLower(format) {
+	case "", "json":
+		if path == "" {
+			timestamp := time.Now().Format("20060102_150405")
+			path = fmt.Sprintf("%s_scan.json", timestamp)
+		}
+		abs, _ := filepath.Abs(path)
+		return newJSONGroupedWriter(abs)
+
+	case "ndjson":
+		w, err := openOutput(path)
+		if err != nil {
+			return nil, err
+		}
+		return &ndjsonWriter{out: w}, nil
+
+	case "csv":
+		w, err := openOutput(path)
+		if err != nil {
+			return nil, err
+		}
+		return &csvWriter{out: w, csv: csv.NewWriter(w)}, nil
+
+	case "prom":
+		w, err := openOutput(path)
+		if err != nil {
+			return nil, err
+		}
+		return &promWriter{out: w}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want json, ndjson, csv, or prom)", format)
+	}
+}
+
+// openOutput returns stdout when path is empty, otherwise creates path.
+func openOutput(path string) (*os.File, error) {
+	if path == "" {
+		return os.Stdout, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating output file: %w", err)
+	}
+
+	return f, nil
+}
+
+// closeIfNotStdout avoids closing os.Stdout out from under the process.
+func closeIfNotStdout(f *os.File) error {
+	if f == os.Stdout {
+		return nil
+	}
+	return f.Close()
+}
+
+// jsonGroupedWriter reproduces the tool's original output: results grouped
+// by status code, with 3xx entries carrying their redirect target. It has
+// to buffer every result, since the grouping can't be known until the run
+// is done.
+type jsonGroupedWriter struct {
+	path    string
+	results map[int][]Result
+	errors  []Result
+}
+
+func newJSONGroupedWriter(path string) (*jsonGroupedWriter, error) {
+	return &jsonGroupedWriter{path: path, results: make(map[int][]Result)}, nil
+}
+
+func (w *jsonGroupedWriter) Write(res Result) error {
+	if res.ErrorClass != "" {
+		w.errors = append(w.errors, res)
+		return nil
+	}
+	w.results[res.StatusCode] = append(w.results[res.StatusCode], res)
+	return nil
+}
+
+func (w *jsonGroupedWriter) Close() error {
+	jsonData := make(map[string]interface{})
+
+	if len(w.errors) > 0 {
+		type errorEntry struct {
+			URL     string `json:"url"`
+			Class   string `json:"error_class"`
+			Message string `json:"message"`
+		}
+
+		entries := make([]errorEntry, 0, len(w.errors))
+		for _, res := range w.errors {
+			entries = append(entries, errorEntry{URL: res.URL, Class: res.ErrorClass, Message: res.ErrorMessage})
+		}
+
+		jsonData["errors"] = entries
+	}
+
+	for statusCode, resList := range w.results {
+		codeStr := strconv.Itoa(statusCode)
+		var entries []interface{}
+
+		for _, res := range resList {
+			if statusCode >= 300 && statusCode < 400 {
+				// For 3xx status codes, include redirection information with URL first
+				entry := struct {
+					URL           string `json:"url"`
+					RedirectedURL string `json:"redirected_url"`
+				}{
+					URL:           res.URL,
+					RedirectedURL: res.RedirectedURL,
+				}
+				entries = append(entries, entry)
+			} else {
+				// For other status codes, just include the URL
+				entries = append(entries, res.URL)
+			}
+		}
+
+		jsonData[codeStr] = entries
+	}
+
+	jsonFile, err := os.Create(w.path)
+	if err != nil {
+		return fmt.Errorf("creating JSON file: %w", err)
+	}
+	defer jsonFile.Close()
+
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(jsonData); err != nil {
+		return fmt.Errorf("writing JSON file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "JSON file created successfully at: %s\n", w.path)
+	return nil
+}
+
+// ndjsonWriter emits one JSON-encoded Result per line as results arrive.
+type ndjsonWriter struct {
+	out *os.File
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(res Result) error {
+	if w.enc == nil {
+		w.enc = json.NewEncoder(w.out)
+	}
+	return w.enc.Encode(res)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return closeIfNotStdout(w.out)
+}
+
+// csvWriter emits one row per result, writing a header on the first row.
+type csvWriter struct {
+	out         *os.File
+	csv         *csv.Writer
+	wroteHeader bool
+}
+
+var csvColumns = []string{
+	"url", "status_code", "redirected_url", "protocol",
+	"cert_cn", "cert_sans", "cert_issuer", "cert_not_before", "cert_not_after",
+	"cert_days_remaining", "cert_expiring_soon", "duration_seconds",
+	"title", "body_sha256", "content_length", "tech",
+	"error_class", "error_message",
+}
+
+func (w *csvWriter) Write(res Result) error {
+	if !w.wroteHeader {
+		if err := w.csv.Write(csvColumns); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+
+	row := []string{
+		res.URL,
+		strconv.Itoa(res.StatusCode),
+		res.RedirectedURL,
+		res.Protocol,
+		res.CertCommonName,
+		strings.Join(res.CertSANs, ";"),
+		res.CertIssuer,
+		res.CertNotBefore,
+		res.CertNotAfter,
+		strconv.Itoa(res.CertDaysRemaining),
+		strconv.FormatBool(res.CertExpiringSoon),
+		strconv.FormatFloat(res.DurationSeconds, 'f', -1, 64),
+		res.Title,
+		res.BodyHash,
+		strconv.FormatInt(res.ContentLength, 10),
+		strings.Join(res.Tech, ";"),
+		res.ErrorClass,
+		res.ErrorMessage,
+	}
+
+	if err := w.csv.Write(row); err != nil {
+		return err
+	}
+
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	return closeIfNotStdout(w.out)
+}
+
+// promWriter emits Prometheus textfile-collector gauges as results arrive:
+// domainjaasoos_probe_status and domainjaasoos_probe_duration_seconds,
+// labeled by url and negotiated proto. HELP/TYPE lines are written once,
+// ahead of the first sample of each metric.
+type promWriter struct {
+	out         *os.File
+	buf         *bufio.Writer
+	wroteHeader bool
+}
+
+func (w *promWriter) Write(res Result) error {
+	if w.buf == nil {
+		w.buf = bufio.NewWriter(w.out)
+	}
+
+	if !w.wroteHeader {
+		fmt.Fprintln(w.buf, "# HELP domainjaasoos_probe_status HTTP status code returned by the probe")
+		fmt.Fprintln(w.buf, "# TYPE domainjaasoos_probe_status gauge")
+		fmt.Fprintln(w.buf, "# HELP domainjaasoos_probe_duration_seconds Time taken to complete the probe")
+		fmt.Fprintln(w.buf, "# TYPE domainjaasoos_probe_duration_seconds gauge")
+		w.wroteHeader = true
+	}
+
+	labels := fmt.Sprintf(`url=%q,proto=%q`, res.URL, res.Protocol)
+	fmt.Fprintf(w.buf, "domainjaasoos_probe_status{%s} %d\n", labels, res.StatusCode)
+	fmt.Fprintf(w.buf, "domainjaasoos_probe_duration_seconds{%s} %s\n", labels, strconv.FormatFloat(res.DurationSeconds, 'f', -1, 64))
+
+	return w.buf.Flush()
+}
+
+func (w *promWriter) Close() error {
+	if w.buf != nil {
+		if err := w.buf.Flush(); err != nil {
+			return err
+		}
+	}
+	return closeIfNotStdout(w.out)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// sleepBackoff waits base * 2^attempt, plus up to 50% jitter, before the
+// next retry. A non-positive base disables the wait entirely.
+func sleepBackoff(base time.Duration, attempt int) {
+	if base <= 0 {
+		return
+	}
+
+	backoff := base * (1 << uint(attempt))
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+
+	time.Sleep(backoff + jitter)
+}